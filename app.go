@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/Noah-Huppert/qmk-gui/lsp"
+
+	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// App is the Wails-bindable API the frontend drives the editor through. Every method translates to the
+// corresponding clangd request and marshals the result into a frontend-friendly DTO - no go.lsp.dev/protocol type
+// leaks across the Wails bridge.
+type App struct {
+	ctx context.Context
+
+	// supervisor owns the LSP connection. It is nil until SetSupervisor is called, e.g. once the initial
+	// Initialize/Initialized handshake has completed.
+	supervisor *lsp.Supervisor
+
+	// caps reports which optional LSP features the connected server supports.
+	caps CapabilitiesDTO
+
+	// semanticTokenLegend is the tokenTypes array the connected server returned in its Initialize response, used to
+	// decode the token type indices in a textDocument/semanticTokens/full response.
+	semanticTokenLegend []string
+}
+
+// NewApp creates a new App application struct
+func NewApp() *App {
+	return &App{}
+}
+
+// startup is called when the app starts. The context is saved so we can call the runtime methods.
+func (a *App) startup(ctx context.Context) {
+	a.ctx = ctx
+}
+
+// Greet returns a greeting for the given name
+func (a *App) Greet(name string) string {
+	return fmt.Sprintf("Hello %s, It's show time!", name)
+}
+
+// SetSupervisor wires the App up to a running Supervisor and starts forwarding its diagnostics and progress
+// notifications to the frontend as Wails events. caps should reflect the capabilities returned by the server's
+// Initialize response, and semanticTokenLegend its negotiated semantic tokens tokenTypes legend (nil if the server
+// doesn't support semantic tokens). Notifications are read from the Supervisor's own notifications hub, which
+// stays valid across a clangd crash-restart, rather than from whichever LSPManager happens to be active at this
+// call.
+func (a *App) SetSupervisor(supervisor *lsp.Supervisor, caps CapabilitiesDTO, semanticTokenLegend []string) {
+	a.supervisor = supervisor
+	a.caps = caps
+	a.semanticTokenLegend = semanticTokenLegend
+
+	a.streamNotifications(supervisor.Notifications())
+}
+
+// Capabilities reports which optional LSP features the connected server supports, so the frontend can hide UI for
+// features clangd doesn't provide.
+func (a *App) Capabilities() CapabilitiesDTO {
+	return a.caps
+}
+
+// server returns the LSP server client to issue requests against, or an error if the LSP connection isn't up yet.
+func (a *App) server() (protocol.Server, error) {
+	manager, err := a.manager()
+	if err != nil {
+		return nil, err
+	}
+
+	return manager.Server().Server, nil
+}
+
+// manager returns the currently active LSPManager, or an error if the LSP connection isn't up yet.
+func (a *App) manager() (*lsp.LSPManager, error) {
+	if a.supervisor == nil {
+		return nil, fmt.Errorf("LSP server is not running")
+	}
+
+	manager := a.supervisor.Manager()
+	if manager == nil {
+		return nil, fmt.Errorf("LSP server is not ready")
+	}
+
+	return manager, nil
+}
+
+// ListSymbols searches the whole workspace for symbols matching query.
+func (a *App) ListSymbols(query string) ([]SymbolDTO, error) {
+	server, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+
+	symbols, err := server.Symbols(a.ctx, &protocol.WorkspaceSymbolParams{
+		Query: query,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list symbols: %s", err)
+	}
+
+	dtos := make([]SymbolDTO, len(symbols))
+	for i, sym := range symbols {
+		dtos[i] = symbolToDTO(sym)
+	}
+
+	return dtos, nil
+}
+
+// Hover returns the rendered hover text clangd has for the symbol at line/char within docURI.
+func (a *App) Hover(docURI string, line int, char int) (*HoverDTO, error) {
+	server, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+
+	hover, err := server.Hover(a.ctx, &protocol.HoverParams{
+		TextDocumentPositionParams: textDocumentPosition(docURI, line, char),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hover: %s", err)
+	}
+
+	return hoverToDTO(hover), nil
+}
+
+// Definition returns the location(s) of the definition of the symbol at line/char within docURI.
+func (a *App) Definition(docURI string, line int, char int) ([]LocationDTO, error) {
+	server, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+
+	locs, err := server.Definition(a.ctx, &protocol.DefinitionParams{
+		TextDocumentPositionParams: textDocumentPosition(docURI, line, char),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get definition: %s", err)
+	}
+
+	return locationsToDTO(locs), nil
+}
+
+// References returns every location referencing the symbol at line/char within docURI.
+func (a *App) References(docURI string, line int, char int) ([]LocationDTO, error) {
+	server, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+
+	locs, err := server.References(a.ctx, &protocol.ReferenceParams{
+		TextDocumentPositionParams: textDocumentPosition(docURI, line, char),
+		Context: protocol.ReferenceContext{
+			IncludeDeclaration: true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get references: %s", err)
+	}
+
+	return locationsToDTO(locs), nil
+}
+
+// SemanticTokens returns the decoded semantic tokens clangd reports for the whole document at docURI.
+func (a *App) SemanticTokens(docURI string) ([]SemanticTokenDTO, error) {
+	server, err := a.server()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := server.SemanticTokensFull(a.ctx, &protocol.SemanticTokensParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: uri.New(docURI),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get semantic tokens: %s", err)
+	}
+	if tokens == nil {
+		return nil, nil
+	}
+
+	return decodeSemanticTokens(tokens.Data, a.semanticTokenLegend), nil
+}
+
+// OpenKeymap opens the keymap.c file at path with the LSP server so it can be edited through EditKeymap, returning
+// its URI for use in subsequent calls.
+func (a *App) OpenKeymap(path string) (string, error) {
+	manager, err := a.manager()
+	if err != nil {
+		return "", err
+	}
+
+	docURI := uri.File(path)
+	if err := manager.Documents().Open(a.ctx, docURI); err != nil {
+		return "", fmt.Errorf("failed to open keymap: %s", err)
+	}
+
+	return string(docURI), nil
+}
+
+// EditKeymap applies edits to the already-open keymap document at docURI.
+func (a *App) EditKeymap(docURI string, edits []TextEditDTO) error {
+	manager, err := a.manager()
+	if err != nil {
+		return err
+	}
+
+	changes := make([]protocol.TextDocumentContentChangeEvent, len(edits))
+	for i, edit := range edits {
+		changes[i] = edit.toContentChangeEvent()
+	}
+
+	if err := manager.Documents().Change(a.ctx, uri.New(docURI), changes); err != nil {
+		return fmt.Errorf("failed to edit keymap: %s", err)
+	}
+
+	return nil
+}
+
+// streamNotifications forwards diagnostics, progress, and show message events from notif to the frontend as
+// Wails events ("lsp:diagnostics", "lsp:progress", "lsp:showMessage") until a.ctx is done.
+func (a *App) streamNotifications(notif *lsp.LSPNotifications) {
+	go func() {
+		for {
+			select {
+			case <-a.ctx.Done():
+				return
+			case params := <-notif.DiagnosticsFanout():
+				wailsRuntime.EventsEmit(a.ctx, "lsp:diagnostics", diagnosticsParamsToDTO(params))
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-a.ctx.Done():
+				return
+			case event := <-notif.Progress():
+				wailsRuntime.EventsEmit(a.ctx, "lsp:progress", progressEventToDTO(event))
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-a.ctx.Done():
+				return
+			case params := <-notif.ShowMessages():
+				wailsRuntime.EventsEmit(a.ctx, "lsp:showMessage", showMessageToDTO(params))
+			}
+		}
+	}()
+}
+
+// textDocumentPosition builds the common TextDocumentPositionParams shape shared by Hover, Definition, and
+// References.
+func textDocumentPosition(docURI string, line int, char int) protocol.TextDocumentPositionParams {
+	return protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: uri.New(docURI),
+		},
+		Position: protocol.Position{
+			Line:      uint32(line),
+			Character: uint32(char),
+		},
+	}
+}
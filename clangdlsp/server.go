@@ -55,6 +55,40 @@ type ServerCapabilities struct {
 	ASTProvider bool `json:"astProvider"`
 }
 
+// ASTParams are the parameters for clangd's textDocument/ast extension request.
+// https://clangd.llvm.org/extensions#ast
+type ASTParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+
+	// Range restricts the dump to the node(s) covering this range, rather than the whole translation unit.
+	Range protocol.Range `json:"range"`
+}
+
+// ASTNode is one node of the AST clangd returns from textDocument/ast. Role and Kind classify the node (e.g. role
+// "expression", kind "InitListExpr"); Detail carries a short pretty-printed form of the node's source text, which
+// for a leaf keycode expression is the keycode identifier itself.
+type ASTNode struct {
+	Role     string         `json:"role"`
+	Kind     string         `json:"kind"`
+	Detail   string         `json:"detail,omitempty"`
+	Arcana   string         `json:"arcana,omitempty"`
+	Range    protocol.Range `json:"range"`
+	Children []ASTNode      `json:"children,omitempty"`
+}
+
+// AST requests clangd's AST extension for the node(s) covering params.Range.
+// https://clangd.llvm.org/extensions#ast
+func (server ClangdServer) AST(ctx context.Context, params *ASTParams) (*ASTNode, error) {
+	var res ASTNode
+
+	server.logger.Debug("call textDocument/ast", zap.Any("params", params))
+	if err := protocol.Call(ctx, server.conn, "textDocument/ast", params, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
 func (server ClangdServer) Initialize(ctx context.Context, params *InitializeParams) (*InitializeResult, error) {
 	// Make request
 	var ifaceRes interface{}
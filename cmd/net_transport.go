@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// NetTransport wraps a connection to an already-running LSP server, for use when qmk-gui should talk to a remote
+// server instead of spawning its own clangd child process (e.g. clangd running inside a container or VM next to
+// the qmk_firmware checkout).
+type NetTransport struct {
+	conn net.Conn
+}
+
+// NewNetTransport dials addr, which must be a "tcp://host:port" or "unix:///path/to/socket" URL, and returns a
+// transport wrapping the resulting connection.
+func NewNetTransport(addr string) (*NetTransport, error) {
+	parsed, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse remote LSP address %q: %s", addr, err)
+	}
+
+	var network, dialAddr string
+	switch parsed.Scheme {
+	case "tcp":
+		network = "tcp"
+		dialAddr = parsed.Host
+	case "unix":
+		network = "unix"
+		dialAddr = parsed.Path
+	default:
+		return nil, fmt.Errorf("unsupported remote LSP scheme %q, expected tcp or unix", parsed.Scheme)
+	}
+
+	conn, err := net.Dial(network, dialAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial remote LSP server at %s: %s", addr, err)
+	}
+
+	return &NetTransport{conn: conn}, nil
+}
+
+// Read reads from the underlying connection.
+func (t *NetTransport) Read(p []byte) (int, error) {
+	return t.conn.Read(p)
+}
+
+// Write writes to the underlying connection.
+func (t *NetTransport) Write(p []byte) (int, error) {
+	return t.conn.Write(p)
+}
+
+// Close closes the underlying connection.
+func (t *NetTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Pid always returns 0 since a remote LSP server isn't a local child process.
+func (t *NetTransport) Pid() int {
+	return 0
+}
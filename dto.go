@@ -0,0 +1,291 @@
+package main
+
+import (
+	"go.lsp.dev/protocol"
+
+	"github.com/Noah-Huppert/qmk-gui/lsp"
+)
+
+// PositionDTO is a zero-indexed line/character position within a document.
+type PositionDTO struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// RangeDTO is a span within a document, from Start up to but not including End.
+type RangeDTO struct {
+	Start PositionDTO `json:"start"`
+	End   PositionDTO `json:"end"`
+}
+
+// LocationDTO is a range within a specific file, e.g. one definition or reference result.
+type LocationDTO struct {
+	URI   string   `json:"uri"`
+	Range RangeDTO `json:"range"`
+}
+
+// SymbolDTO describes one workspace symbol search result.
+type SymbolDTO struct {
+	Name     string      `json:"name"`
+	Kind     string      `json:"kind"`
+	Location LocationDTO `json:"location"`
+}
+
+// HoverDTO is the rendered hover text for a position. Found is false if the server had nothing to show.
+type HoverDTO struct {
+	Found    bool      `json:"found"`
+	Contents string    `json:"contents"`
+	Range    *RangeDTO `json:"range,omitempty"`
+}
+
+// TextEditDTO is a single replacement within a document, as sent by the frontend to EditKeymap.
+type TextEditDTO struct {
+	Range RangeDTO `json:"range"`
+	Text  string   `json:"text"`
+}
+
+// SemanticTokenDTO is one decoded token from a textDocument/semanticTokens/full response.
+type SemanticTokenDTO struct {
+	Range     RangeDTO `json:"range"`
+	TokenType string   `json:"tokenType"`
+}
+
+// CapabilitiesDTO reports which optional LSP features the connected server supports.
+type CapabilitiesDTO struct {
+	ASTProvider             bool `json:"astProvider"`
+	WorkspaceSymbolProvider bool `json:"workspaceSymbolProvider"`
+}
+
+// DiagnosticDTO is one diagnostic reported against a document.
+type DiagnosticDTO struct {
+	Range    RangeDTO `json:"range"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// DiagnosticsEventDTO is the payload of an "lsp:diagnostics" Wails event.
+type DiagnosticsEventDTO struct {
+	URI         string          `json:"uri"`
+	Diagnostics []DiagnosticDTO `json:"diagnostics"`
+}
+
+// ProgressEventDTO is the payload of an "lsp:progress" Wails event.
+type ProgressEventDTO struct {
+	Token      string `json:"token"`
+	Kind       string `json:"kind"`
+	Title      string `json:"title,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Percentage uint32 `json:"percentage,omitempty"`
+}
+
+// ShowMessageDTO is the payload of an "lsp:showMessage" Wails event.
+type ShowMessageDTO struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func positionToDTO(pos protocol.Position) PositionDTO {
+	return PositionDTO{
+		Line:      int(pos.Line),
+		Character: int(pos.Character),
+	}
+}
+
+func (pos PositionDTO) toProtocol() protocol.Position {
+	return protocol.Position{
+		Line:      uint32(pos.Line),
+		Character: uint32(pos.Character),
+	}
+}
+
+func rangeToDTO(r protocol.Range) RangeDTO {
+	return RangeDTO{
+		Start: positionToDTO(r.Start),
+		End:   positionToDTO(r.End),
+	}
+}
+
+func (r RangeDTO) toProtocol() protocol.Range {
+	return protocol.Range{
+		Start: r.Start.toProtocol(),
+		End:   r.End.toProtocol(),
+	}
+}
+
+func locationToDTO(loc protocol.Location) LocationDTO {
+	return LocationDTO{
+		URI:   string(loc.URI),
+		Range: rangeToDTO(loc.Range),
+	}
+}
+
+func locationsToDTO(locs []protocol.Location) []LocationDTO {
+	dtos := make([]LocationDTO, len(locs))
+	for i, loc := range locs {
+		dtos[i] = locationToDTO(loc)
+	}
+	return dtos
+}
+
+func symbolToDTO(sym protocol.SymbolInformation) SymbolDTO {
+	return SymbolDTO{
+		Name:     sym.Name,
+		Kind:     sym.Kind.String(),
+		Location: locationToDTO(sym.Location),
+	}
+}
+
+func hoverToDTO(hover *protocol.Hover) *HoverDTO {
+	if hover == nil {
+		return &HoverDTO{Found: false}
+	}
+
+	dto := &HoverDTO{
+		Found:    true,
+		Contents: hover.Contents.Value,
+	}
+	if hover.Range != nil {
+		r := rangeToDTO(*hover.Range)
+		dto.Range = &r
+	}
+
+	return dto
+}
+
+// toContentChangeEvent converts a frontend-supplied edit into the incremental LSP content change it represents.
+func (edit TextEditDTO) toContentChangeEvent() protocol.TextDocumentContentChangeEvent {
+	return protocol.TextDocumentContentChangeEvent{
+		Range: edit.Range.toProtocol(),
+		Text:  edit.Text,
+	}
+}
+
+// decodeSemanticTokens decodes the LSP semantic tokens delta encoding (relative line, start character, length,
+// token type index, modifiers bitset, repeated) into absolute ranges. legend is the tokenTypes array the server
+// actually returned in its Initialize response (see extractSemanticTokensLegend); a token type index the legend
+// doesn't cover (e.g. because legend is empty or the server revises it) decodes to an empty TokenType rather than
+// panicking.
+func decodeSemanticTokens(data []uint32, legend []string) []SemanticTokenDTO {
+	tokens := make([]SemanticTokenDTO, 0, len(data)/5)
+
+	var line, char uint32
+	for i := 0; i+4 < len(data); i += 5 {
+		deltaLine := data[i]
+		deltaStart := data[i+1]
+		length := data[i+2]
+		tokenType := data[i+3]
+
+		if deltaLine > 0 {
+			line += deltaLine
+			char = deltaStart
+		} else {
+			char += deltaStart
+		}
+
+		var tokenTypeName string
+		if int(tokenType) < len(legend) {
+			tokenTypeName = legend[tokenType]
+		}
+
+		tokens = append(tokens, SemanticTokenDTO{
+			Range: RangeDTO{
+				Start: PositionDTO{Line: int(line), Character: int(char)},
+				End:   PositionDTO{Line: int(line), Character: int(char + length)},
+			},
+			TokenType: tokenTypeName,
+		})
+	}
+
+	return tokens
+}
+
+// extractSemanticTokensLegend pulls the tokenTypes legend out of the Initialize response's semanticTokensProvider
+// capability. That capability is decoded as an untyped interface{} (it's either a bool or a SemanticTokensOptions
+// per the LSP spec), so it arrives as a generic map rather than a concrete struct; returns nil if the server didn't
+// advertise semantic tokens support at all.
+func extractSemanticTokensLegend(semanticTokensProvider interface{}) []string {
+	opts, ok := semanticTokensProvider.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	legend, ok := opts["legend"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	rawTypes, ok := legend["tokenTypes"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	types := make([]string, 0, len(rawTypes))
+	for _, rawType := range rawTypes {
+		if t, ok := rawType.(string); ok {
+			types = append(types, t)
+		}
+	}
+
+	return types
+}
+
+func diagnosticSeverity(sev protocol.DiagnosticSeverity) string {
+	switch sev {
+	case protocol.DiagnosticSeverityError:
+		return "error"
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	case protocol.DiagnosticSeverityInformation:
+		return "information"
+	case protocol.DiagnosticSeverityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+func diagnosticsParamsToDTO(params protocol.PublishDiagnosticsParams) DiagnosticsEventDTO {
+	diagnostics := make([]DiagnosticDTO, len(params.Diagnostics))
+	for i, diag := range params.Diagnostics {
+		diagnostics[i] = DiagnosticDTO{
+			Range:    rangeToDTO(diag.Range),
+			Severity: diagnosticSeverity(diag.Severity),
+			Message:  diag.Message,
+		}
+	}
+
+	return DiagnosticsEventDTO{
+		URI:         string(params.URI),
+		Diagnostics: diagnostics,
+	}
+}
+
+func progressEventToDTO(event lsp.ProgressEvent) ProgressEventDTO {
+	return ProgressEventDTO{
+		Token:      event.Token.String(),
+		Kind:       event.Kind,
+		Title:      event.Title,
+		Message:    event.Message,
+		Percentage: event.Percentage,
+	}
+}
+
+func messageTypeSeverity(msgType protocol.MessageType) string {
+	switch msgType {
+	case protocol.MessageTypeError:
+		return "error"
+	case protocol.MessageTypeWarning:
+		return "warning"
+	case protocol.MessageTypeInfo:
+		return "info"
+	default:
+		return "log"
+	}
+}
+
+func showMessageToDTO(params protocol.ShowMessageParams) ShowMessageDTO {
+	return ShowMessageDTO{
+		Severity: messageTypeSeverity(params.Type),
+		Message:  params.Message,
+	}
+}
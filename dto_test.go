@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeSemanticTokens(t *testing.T) {
+	legend := []string{"namespace", "type", "variable"}
+
+	tests := []struct {
+		name   string
+		data   []uint32
+		legend []string
+		want   []SemanticTokenDTO
+	}{
+		{
+			name:   "single token",
+			data:   []uint32{0, 0, 5, 2, 0},
+			legend: legend,
+			want: []SemanticTokenDTO{
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 0, Character: 0},
+						End:   PositionDTO{Line: 0, Character: 5},
+					},
+					TokenType: "variable",
+				},
+			},
+		},
+		{
+			name: "second token on the same line is relative to the first",
+			data: []uint32{
+				0, 0, 4, 1, 0,
+				0, 5, 3, 0, 0,
+			},
+			legend: legend,
+			want: []SemanticTokenDTO{
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 0, Character: 0},
+						End:   PositionDTO{Line: 0, Character: 4},
+					},
+					TokenType: "type",
+				},
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 0, Character: 5},
+						End:   PositionDTO{Line: 0, Character: 8},
+					},
+					TokenType: "namespace",
+				},
+			},
+		},
+		{
+			name: "token on a later line resets the character offset",
+			data: []uint32{
+				0, 0, 1, 2, 0,
+				2, 3, 1, 1, 0,
+			},
+			legend: legend,
+			want: []SemanticTokenDTO{
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 0, Character: 0},
+						End:   PositionDTO{Line: 0, Character: 1},
+					},
+					TokenType: "variable",
+				},
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 2, Character: 3},
+						End:   PositionDTO{Line: 2, Character: 4},
+					},
+					TokenType: "type",
+				},
+			},
+		},
+		{
+			name:   "token type index beyond the legend decodes to an empty type instead of panicking",
+			data:   []uint32{0, 0, 1, 99, 0},
+			legend: legend,
+			want: []SemanticTokenDTO{
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 0, Character: 0},
+						End:   PositionDTO{Line: 0, Character: 1},
+					},
+					TokenType: "",
+				},
+			},
+		},
+		{
+			name:   "nil legend decodes every token to an empty type",
+			data:   []uint32{0, 0, 1, 0, 0},
+			legend: nil,
+			want: []SemanticTokenDTO{
+				{
+					Range: RangeDTO{
+						Start: PositionDTO{Line: 0, Character: 0},
+						End:   PositionDTO{Line: 0, Character: 1},
+					},
+					TokenType: "",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := decodeSemanticTokens(test.data, test.legend)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeSemanticTokens() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtractSemanticTokensLegend(t *testing.T) {
+	tests := []struct {
+		name                   string
+		semanticTokensProvider interface{}
+		want                   []string
+	}{
+		{
+			name: "options with a legend",
+			semanticTokensProvider: map[string]interface{}{
+				"legend": map[string]interface{}{
+					"tokenTypes": []interface{}{"namespace", "type", "variable"},
+				},
+			},
+			want: []string{"namespace", "type", "variable"},
+		},
+		{
+			name:                   "bool capability has no legend",
+			semanticTokensProvider: true,
+			want:                   nil,
+		},
+		{
+			name:                   "capability not present",
+			semanticTokensProvider: nil,
+			want:                   nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := extractSemanticTokensLegend(test.semanticTokensProvider)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("extractSemanticTokensLegend() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
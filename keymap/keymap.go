@@ -0,0 +1,325 @@
+// Package keymap extracts a structured, editable model of a QMK keymap.c's keymaps[][MATRIX_ROWS][MATRIX_COLS]
+// initializer from clangd's AST, layered over the LSP document sync machinery in the lsp package.
+package keymap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/Noah-Huppert/qmk-gui/clangdlsp"
+	"github.com/Noah-Huppert/qmk-gui/lsp"
+)
+
+// Keycode is a QMK keycode identifier, e.g. "KC_A" or "LT(1, KC_SPC)".
+type Keycode string
+
+// Cell is one key within a Layer: its current Keycode and the source range of the initializer expression backing
+// it, so SetKey can replace just that expression instead of rewriting the whole file.
+type Cell struct {
+	Keycode Keycode
+	Range   protocol.Range
+}
+
+// Layer is one keymap layer, Rows[row][col] holding that key's Cell.
+type Layer struct {
+	Rows [][]Cell
+}
+
+// Layout is the full set of layers parsed from a keymap.c's keymaps initializer.
+type Layout struct {
+	// URI is the keymap.c document this Layout was parsed from, and that SetKey edits.
+	URI uri.URI
+
+	// MatrixRows and MatrixCols come from the keyboard's #define MATRIX_ROWS / MATRIX_COLS.
+	MatrixRows int
+	MatrixCols int
+
+	Layers []Layer
+}
+
+// Parse extracts the Layout from the keymap.c document at docURI. It locates the keymaps initializer via
+// textDocument/documentSymbol, then walks its structure via clangd's AST extension, and reads MATRIX_ROWS /
+// MATRIX_COLS from the keyboard's config.h.
+func Parse(ctx context.Context, server clangdlsp.ClangdServer, docURI uri.URI, configHPath string) (*Layout, error) {
+	matrixRows, matrixCols, err := matrixDims(configHPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read matrix dimensions: %s", err)
+	}
+
+	symbols, err := server.DocumentSymbol(ctx, &protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: docURI},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document symbols: %s", err)
+	}
+
+	keymapsRange, err := findKeymapsRange(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	keymapsNode, err := server.AST(ctx, &clangdlsp.ASTParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: docURI},
+		Range:        keymapsRange,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AST for keymaps initializer: %s", err)
+	}
+
+	// clangd returns the VarDecl covering the "keymaps" symbol's range, not the InitListExpr itself - the
+	// initializer is nested somewhere beneath it.
+	initList := findInitListExpr(keymapsNode)
+	if initList == nil {
+		return nil, fmt.Errorf("no InitListExpr found in AST for keymaps initializer")
+	}
+
+	layerNodes := initList.Children
+	layers := make([]Layer, 0, len(layerNodes))
+	for _, layerNode := range layerNodes {
+		cellNodes := layerKeycodeCells(&layerNode)
+
+		rows := make([][]Cell, 0, matrixRows)
+		for row := 0; row < matrixRows; row++ {
+			cells := make([]Cell, 0, matrixCols)
+			for col := 0; col < matrixCols; col++ {
+				i := row*matrixCols + col
+				if i >= len(cellNodes) {
+					break
+				}
+
+				cellNode := cellNodes[i]
+				cells = append(cells, Cell{
+					Keycode: Keycode(strings.TrimSpace(cellNode.Detail)),
+					Range:   cellNode.Range,
+				})
+			}
+			rows = append(rows, cells)
+		}
+		layers = append(layers, Layer{Rows: rows})
+	}
+
+	return &Layout{
+		URI:        docURI,
+		MatrixRows: matrixRows,
+		MatrixCols: matrixCols,
+		Layers:     layers,
+	}, nil
+}
+
+// SetKey computes the byte range of the keycode at (layer, row, col), as captured when the Layout was parsed, and
+// emits an incremental textDocument/didChange edit through docColl to replace it with keycode. Every cached
+// Cell.Range that starts later on the same source line is shifted by the resulting length delta, so a second SetKey on the same row
+// still targets the right bytes even though keycode identifiers aren't all the same length (e.g. "KC_A" vs
+// "LT(1, KC_SPC)"). This assumes, as QMK keymaps always do in practice, that no keycode expression itself spans
+// multiple lines; SetKey does not attempt to correct ranges on lines other than the edited one.
+func (layout *Layout) SetKey(ctx context.Context, docColl *lsp.LSPDocumentCollection, layer int, row int, col int, keycode Keycode) error {
+	if layer < 0 || layer >= len(layout.Layers) {
+		return fmt.Errorf("layer %d out of range, layout has %d layers", layer, len(layout.Layers))
+	}
+	rows := layout.Layers[layer].Rows
+
+	if row < 0 || row >= len(rows) {
+		return fmt.Errorf("row %d out of range, layer has %d rows", row, len(rows))
+	}
+	cells := rows[row]
+
+	if col < 0 || col >= len(cells) {
+		return fmt.Errorf("col %d out of range, row has %d cols", col, len(cells))
+	}
+	cell := &cells[col]
+	oldRange := cell.Range
+	newText := string(keycode)
+
+	err := docColl.Change(ctx, layout.URI, []protocol.TextDocumentContentChangeEvent{
+		{
+			Range: oldRange,
+			Text:  newText,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set key: %s", err)
+	}
+
+	cell.Keycode = keycode
+	cell.Range = protocol.Range{
+		Start: oldRange.Start,
+		End: protocol.Position{
+			Line:      oldRange.Start.Line,
+			Character: oldRange.Start.Character + uint32(len(newText)),
+		},
+	}
+
+	delta := int32(len(newText)) - int32(oldRange.End.Character-oldRange.Start.Character)
+	if delta != 0 {
+		layout.shiftSameLineRanges(oldRange.Start.Line, oldRange.Start.Character, delta, cell)
+	}
+
+	return nil
+}
+
+// shiftSameLineRanges adjusts the cached Range of every cell other than edited whose Range starts on line after
+// character, by delta characters, to account for a just-applied edit that changed the length of the text at
+// (line, character).
+func (layout *Layout) shiftSameLineRanges(line uint32, character uint32, delta int32, edited *Cell) {
+	for li := range layout.Layers {
+		for ri := range layout.Layers[li].Rows {
+			for ci := range layout.Layers[li].Rows[ri] {
+				other := &layout.Layers[li].Rows[ri][ci]
+				if other == edited || other.Range.Start.Line != line || other.Range.Start.Character <= character {
+					continue
+				}
+
+				other.Range.Start.Character = uint32(int32(other.Range.Start.Character) + delta)
+				other.Range.End.Character = uint32(int32(other.Range.End.Character) + delta)
+			}
+		}
+	}
+}
+
+// findInitListExpr searches node and its descendants, depth-first, for the first node of Kind "InitListExpr". The
+// range clangd hands back for a symbol's AST generally covers its declaration (e.g. a VarDecl), with the
+// initializer nested a level or two beneath it, rather than being the InitListExpr itself.
+func findInitListExpr(node *clangdlsp.ASTNode) *clangdlsp.ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	if node.Kind == "InitListExpr" {
+		return node
+	}
+
+	for i := range node.Children {
+		if found := findInitListExpr(&node.Children[i]); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// layerKeycodeCells returns one layer's keycode cells, in row-major matrix order, regardless of whether the layer
+// is written as a raw nested array literal or wrapped in a LAYOUT(...)-style macro.
+func layerKeycodeCells(layerNode *clangdlsp.ASTNode) []clangdlsp.ASTNode {
+	switch layerNode.Kind {
+	case "CallExpr":
+		// QMK keymaps wrap each layer in a LAYOUT(...) macro. clangd expands this to a CallExpr whose first child
+		// is the callee expression and whose remaining children are the per-key keycode arguments, already given
+		// in row-major matrix order rather than nested per row.
+		if len(layerNode.Children) == 0 {
+			return nil
+		}
+		return layerNode.Children[1:]
+
+	case "InitListExpr":
+		// A layer written as a raw nested array literal: each child is a row's InitListExpr, and each row's
+		// children are its individual keycode cells.
+		var cells []clangdlsp.ASTNode
+		for _, rowNode := range layerNode.Children {
+			cells = append(cells, rowNode.Children...)
+		}
+		return cells
+
+	default:
+		return nil
+	}
+}
+
+// findKeymapsRange finds the "keymaps" array variable's source range among the results of a
+// textDocument/documentSymbol request. protocol.Server.DocumentSymbol returns the LSP spec's
+// DocumentSymbol[] | SymbolInformation[] union as []interface{}, since go.lsp.dev/protocol doesn't expose a
+// concrete union type, so rawSymbols is decoded into whichever of the two shapes it actually holds before being
+// searched.
+func findKeymapsRange(rawSymbols []interface{}) (protocol.Range, error) {
+	if len(rawSymbols) == 0 {
+		return protocol.Range{}, fmt.Errorf("no document symbols returned")
+	}
+
+	raw, err := json.Marshal(rawSymbols)
+	if err != nil {
+		return protocol.Range{}, fmt.Errorf("failed to marshal document symbols: %s", err)
+	}
+
+	// Hierarchical DocumentSymbols carry their own "range"; flat SymbolInformation instead nests one inside
+	// "location". Peeking at the first element's keys is enough to tell the two shapes apart.
+	var probe []map[string]interface{}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return protocol.Range{}, fmt.Errorf("failed to inspect document symbols: %s", err)
+	}
+
+	if _, ok := probe[0]["location"]; ok {
+		var symbols []protocol.SymbolInformation
+		if err := json.Unmarshal(raw, &symbols); err != nil {
+			return protocol.Range{}, fmt.Errorf("failed to unmarshal symbol information: %s", err)
+		}
+
+		for _, sym := range symbols {
+			if sym.Name == "keymaps" {
+				return sym.Location.Range, nil
+			}
+		}
+
+		return protocol.Range{}, fmt.Errorf("keymaps symbol not found in document")
+	}
+
+	var symbols []protocol.DocumentSymbol
+	if err := json.Unmarshal(raw, &symbols); err != nil {
+		return protocol.Range{}, fmt.Errorf("failed to unmarshal document symbols: %s", err)
+	}
+
+	return findKeymapsRangeInDocumentSymbols(symbols)
+}
+
+// findKeymapsRangeInDocumentSymbols recursively searches hierarchical document symbols (recursively, since QMK
+// wraps keymaps in a translation unit rather than a namespace) for the "keymaps" array variable.
+func findKeymapsRangeInDocumentSymbols(symbols []protocol.DocumentSymbol) (protocol.Range, error) {
+	for _, sym := range symbols {
+		if sym.Name == "keymaps" {
+			return sym.Range, nil
+		}
+
+		if r, err := findKeymapsRangeInDocumentSymbols(sym.Children); err == nil {
+			return r, nil
+		}
+	}
+
+	return protocol.Range{}, fmt.Errorf("keymaps symbol not found in document")
+}
+
+var (
+	matrixRowsPattern = regexp.MustCompile(`#define\s+MATRIX_ROWS\s+(\d+)`)
+	matrixColsPattern = regexp.MustCompile(`#define\s+MATRIX_COLS\s+(\d+)`)
+)
+
+// matrixDims reads MATRIX_ROWS and MATRIX_COLS out of the keyboard's config.h.
+func matrixDims(configHPath string) (rows int, cols int, err error) {
+	content, err := os.ReadFile(configHPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %s", configHPath, err)
+	}
+
+	rowsMatch := matrixRowsPattern.FindSubmatch(content)
+	colsMatch := matrixColsPattern.FindSubmatch(content)
+	if rowsMatch == nil || colsMatch == nil {
+		return 0, 0, fmt.Errorf("MATRIX_ROWS/MATRIX_COLS #define not found in %s", configHPath)
+	}
+
+	rows, err = strconv.Atoi(string(rowsMatch[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse MATRIX_ROWS: %s", err)
+	}
+
+	cols, err = strconv.Atoi(string(colsMatch[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse MATRIX_COLS: %s", err)
+	}
+
+	return rows, cols, nil
+}
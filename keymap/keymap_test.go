@@ -0,0 +1,124 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Noah-Huppert/qmk-gui/clangdlsp"
+)
+
+func TestMatrixDims(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantRows int
+		wantCols int
+		wantErr  bool
+	}{
+		{
+			name: "valid defines",
+			content: "#pragma once\n" +
+				"#define MATRIX_ROWS 4\n" +
+				"#define MATRIX_COLS 12\n",
+			wantRows: 4,
+			wantCols: 12,
+		},
+		{
+			name:    "missing defines",
+			content: "#pragma once\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.h")
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatalf("failed to write test config.h: %s", err)
+			}
+
+			rows, cols, err := matrixDims(path)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("matrixDims() = (%d, %d, nil), want an error", rows, cols)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matrixDims() returned unexpected error: %s", err)
+			}
+			if rows != test.wantRows || cols != test.wantCols {
+				t.Errorf("matrixDims() = (%d, %d), want (%d, %d)", rows, cols, test.wantRows, test.wantCols)
+			}
+		})
+	}
+}
+
+func TestFindInitListExpr(t *testing.T) {
+	leaf := clangdlsp.ASTNode{Kind: "DeclRefExpr", Detail: "KC_A"}
+	initList := clangdlsp.ASTNode{Kind: "InitListExpr", Children: []clangdlsp.ASTNode{leaf}}
+	varDecl := clangdlsp.ASTNode{Kind: "VarDecl", Children: []clangdlsp.ASTNode{initList}}
+
+	if found := findInitListExpr(&varDecl); found == nil || found.Kind != "InitListExpr" {
+		t.Fatalf("findInitListExpr() = %+v, want the nested InitListExpr", found)
+	}
+
+	if found := findInitListExpr(&leaf); found != nil {
+		t.Fatalf("findInitListExpr() = %+v, want nil when no descendant is an InitListExpr", found)
+	}
+
+	if found := findInitListExpr(nil); found != nil {
+		t.Fatalf("findInitListExpr(nil) = %+v, want nil", found)
+	}
+}
+
+func TestLayerKeycodeCells(t *testing.T) {
+	keycodeLeaf := func(detail string) clangdlsp.ASTNode {
+		return clangdlsp.ASTNode{Kind: "DeclRefExpr", Detail: detail}
+	}
+
+	t.Run("LAYOUT macro call expression", func(t *testing.T) {
+		layer := clangdlsp.ASTNode{
+			Kind: "CallExpr",
+			Children: []clangdlsp.ASTNode{
+				{Kind: "ImplicitCastExpr", Detail: "LAYOUT"}, // callee, must be dropped
+				keycodeLeaf("KC_A"),
+				keycodeLeaf("KC_B"),
+			},
+		}
+
+		cells := layerKeycodeCells(&layer)
+		if len(cells) != 2 || cells[0].Detail != "KC_A" || cells[1].Detail != "KC_B" {
+			t.Fatalf("layerKeycodeCells() = %+v, want [KC_A, KC_B]", cells)
+		}
+	})
+
+	t.Run("raw nested array literal", func(t *testing.T) {
+		layer := clangdlsp.ASTNode{
+			Kind: "InitListExpr",
+			Children: []clangdlsp.ASTNode{
+				{Kind: "InitListExpr", Children: []clangdlsp.ASTNode{keycodeLeaf("KC_A"), keycodeLeaf("KC_B")}},
+				{Kind: "InitListExpr", Children: []clangdlsp.ASTNode{keycodeLeaf("KC_C"), keycodeLeaf("KC_D")}},
+			},
+		}
+
+		cells := layerKeycodeCells(&layer)
+		want := []string{"KC_A", "KC_B", "KC_C", "KC_D"}
+		if len(cells) != len(want) {
+			t.Fatalf("layerKeycodeCells() = %+v, want %d cells", cells, len(want))
+		}
+		for i, w := range want {
+			if cells[i].Detail != w {
+				t.Errorf("cells[%d].Detail = %q, want %q", i, cells[i].Detail, w)
+			}
+		}
+	})
+
+	t.Run("unrecognized kind", func(t *testing.T) {
+		layer := clangdlsp.ASTNode{Kind: "BinaryOperator"}
+		if cells := layerKeycodeCells(&layer); cells != nil {
+			t.Fatalf("layerKeycodeCells() = %+v, want nil", cells)
+		}
+	})
+}
@@ -7,19 +7,34 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"go.lsp.dev/protocol"
 	"go.lsp.dev/uri"
+	"go.uber.org/zap"
 )
 
-// Wraps the LSP did open and did close flow.
+// debounceDelay is how long LSPDocumentCollection waits after the most recent edit before nudging clangd to
+// redeliver diagnostics for reverse dependencies, so rapid typing doesn't swamp the server with requests.
+const debounceDelay = 300 * time.Millisecond
+
+// Wraps the LSP did open, did change, did save, and did close flow for a single file.
 type LSPDocument struct {
 	server protocol.Server
 	uri    uri.URI
+
+	// version is the document version last sent to the LSP server. Incremented on every didChange notification
+	// as required by the LSP spec.
+	version int32
+
+	// content mirrors what the LSP server believes the file contains. Needed to compute full-document syncs and
+	// to apply incremental edits before re-sending them as a full sync if the server requires it.
+	content string
 }
 
 // Opens a file.
-func (doc LSPDocument) Open(ctx context.Context) error {
+func (doc *LSPDocument) Open(ctx context.Context) error {
 	// Read file
 	fileBytes, err := os.ReadFile(doc.uri.Filename())
 	if err != nil {
@@ -41,11 +56,63 @@ func (doc LSPDocument) Open(ctx context.Context) error {
 		return fmt.Errorf("failed to call LSP open: %s", err)
 	}
 
+	doc.version = 0
+	doc.content = fileContents
+
+	return nil
+}
+
+// Change sends a textDocument/didChange notification for edits, respecting the server's declared
+// TextDocumentSyncKind: incremental edits are forwarded as-is, full sync servers instead receive the whole
+// document text recomputed from the edits.
+func (doc *LSPDocument) Change(ctx context.Context, syncKind protocol.TextDocumentSyncKind, edits []protocol.TextDocumentContentChangeEvent) error {
+	doc.version++
+
+	for _, edit := range edits {
+		doc.content = applyContentChange(doc.content, edit)
+	}
+
+	changes := edits
+	if syncKind == protocol.TextDocumentSyncKindFull {
+		changes = []protocol.TextDocumentContentChangeEvent{
+			{Text: doc.content},
+		}
+	}
+
+	err := doc.server.DidChange(ctx, &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+				URI: doc.uri,
+			},
+			Version: doc.version,
+		},
+		ContentChanges: changes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to call LSP did change: %s", err)
+	}
+
+	return nil
+}
+
+// Save sends a textDocument/didSave notification with the document's current content.
+func (doc *LSPDocument) Save(ctx context.Context) error {
+	text := doc.content
+	err := doc.server.DidSave(ctx, &protocol.DidSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: doc.uri,
+		},
+		Text: text,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to call LSP did save: %s", err)
+	}
+
 	return nil
 }
 
 // Closes a document.
-func (doc LSPDocument) Close(ctx context.Context) error {
+func (doc *LSPDocument) Close(ctx context.Context) error {
 	err := doc.server.DidClose(ctx, &protocol.DidCloseTextDocumentParams{
 		TextDocument: protocol.TextDocumentIdentifier{
 			URI: doc.uri,
@@ -59,31 +126,252 @@ func (doc LSPDocument) Close(ctx context.Context) error {
 	return nil
 }
 
-// Collection of documents.
+// applyContentChange applies a single incremental TextDocumentContentChangeEvent to content, returning the
+// resulting text. Change only ever routes edits from callers, which always populate Range; the full-document
+// replace sent to servers that declare TextDocumentSyncKindFull is built separately in Change, after syncKind is
+// known, and never passes through here.
+func applyContentChange(content string, change protocol.TextDocumentContentChangeEvent) string {
+	lines := strings.Split(content, "\n")
+
+	startLine := int(change.Range.Start.Line)
+	endLine := int(change.Range.End.Line)
+	if startLine < 0 || startLine >= len(lines) || endLine < 0 || endLine >= len(lines) {
+		// Out of range edit, fall back to treating it as a full replace rather than panicking on a bad offset.
+		return change.Text
+	}
+
+	before := strings.Join(lines[:startLine], "\n")
+	if startLine > 0 {
+		before += "\n"
+	}
+	before += lines[startLine][:min(int(change.Range.Start.Character), len(lines[startLine]))]
+
+	after := lines[endLine][min(int(change.Range.End.Character), len(lines[endLine])):]
+	if endLine < len(lines)-1 {
+		after += "\n" + strings.Join(lines[endLine+1:], "\n")
+	}
+
+	return before + change.Text + after
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Collection of documents, keyed by URI so changes and saves can be routed to the right LSPDocument.
 type LSPDocumentCollection struct {
-	server    protocol.Server
-	documents []LSPDocument
+	server   protocol.Server
+	syncKind protocol.TextDocumentSyncKind
+	notif    *LSPNotifications
+	logger   *zap.Logger
+
+	mu        sync.Mutex
+	documents map[uri.URI]*LSPDocument
+
+	// debounceCancel cancels the in flight re-diagnose goroutine started by the most recent edit, if any.
+	debounceCancel context.CancelFunc
+}
+
+// NewLSPDocumentCollection creates a new LSPDocumentCollection.
+// syncKind is the TextDocumentSyncKind the LSP server declared in its Initialize response, and governs whether
+// Change sends incremental or full-document updates. notif is used to invalidate cached diagnostics when a
+// document changes.
+func NewLSPDocumentCollection(server protocol.Server, syncKind protocol.TextDocumentSyncKind, notif *LSPNotifications, logger *zap.Logger) LSPDocumentCollection {
+	return LSPDocumentCollection{
+		server:    server,
+		syncKind:  syncKind,
+		notif:     notif,
+		logger:    logger,
+		documents: map[uri.URI]*LSPDocument{},
+	}
+}
+
+// SetSyncKind updates the TextDocumentSyncKind used for future calls to Change. Called once the LSP server's
+// Initialize response is known, since the sync kind isn't available when the collection is first constructed.
+func (coll *LSPDocumentCollection) SetSyncKind(kind protocol.TextDocumentSyncKind) {
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+	coll.syncKind = kind
+}
+
+// ReplaceServer points the collection, and every document it currently tracks, at a new protocol.Server, so
+// in-flight documents talk to that server from then on. It sends no didOpen; documents the new server has not seen
+// are not reopened here.
+func (coll *LSPDocumentCollection) ReplaceServer(server protocol.Server) {
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	coll.server = server
+	for _, doc := range coll.documents {
+		doc.server = server
+	}
+}
+
+// DocumentSnapshot is enough information to reopen a document against a freshly (re)started LSP server.
+type DocumentSnapshot struct {
+	URI     uri.URI
+	Version int32
+	Content string
+}
+
+// Snapshots returns the current state of every open document, for reopening against a restarted LSP server.
+func (coll *LSPDocumentCollection) Snapshots() []DocumentSnapshot {
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	snapshots := make([]DocumentSnapshot, 0, len(coll.documents))
+	for docURI, doc := range coll.documents {
+		snapshots = append(snapshots, DocumentSnapshot{
+			URI:     docURI,
+			Version: doc.version,
+			Content: doc.content,
+		})
+	}
+
+	return snapshots
+}
+
+// Reopen re-sends textDocument/didOpen for a document from a previously captured snapshot, at its last known
+// version, rather than re-reading the file from disk. Used by Supervisor to restore in-memory editor state after
+// clangd restarts, since the on-disk file may be behind unsaved edits.
+func (coll *LSPDocumentCollection) Reopen(ctx context.Context, snapshot DocumentSnapshot) error {
+	coll.mu.Lock()
+	server := coll.server
+	coll.mu.Unlock()
+
+	err := server.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        snapshot.URI,
+			LanguageID: "c",
+			Version:    snapshot.Version,
+			Text:       snapshot.Content,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reopen document: %s", err)
+	}
+
+	coll.mu.Lock()
+	coll.documents[snapshot.URI] = &LSPDocument{
+		server:  server,
+		uri:     snapshot.URI,
+		version: snapshot.Version,
+		content: snapshot.Content,
+	}
+	coll.mu.Unlock()
+
+	return nil
 }
 
 // Open a file
-func (coll LSPDocumentCollection) Open(ctx context.Context, uri uri.URI) error {
-	doc := LSPDocument{
+func (coll *LSPDocumentCollection) Open(ctx context.Context, docURI uri.URI) error {
+	doc := &LSPDocument{
 		server: coll.server,
-		uri:    uri,
+		uri:    docURI,
 	}
 	if err := doc.Open(ctx); err != nil {
 		return fmt.Errorf("failed to open document: %s", err)
 	}
 
-	coll.documents = append(coll.documents, doc)
+	coll.mu.Lock()
+	coll.documents[docURI] = doc
+	coll.mu.Unlock()
 
 	return nil
 }
 
-func (coll LSPDocumentCollection) CloseAll(ctx context.Context) error {
-	errs := []string{}
+// Change applies edits to the document at uri and sends the corresponding textDocument/didChange notification.
+func (coll *LSPDocumentCollection) Change(ctx context.Context, docURI uri.URI, edits []protocol.TextDocumentContentChangeEvent) error {
+	coll.mu.Lock()
+	doc, ok := coll.documents[docURI]
+	coll.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("document not open: %s", docURI)
+	}
+
+	if err := doc.Change(ctx, coll.syncKind, edits); err != nil {
+		return fmt.Errorf("failed to change document: %s", err)
+	}
+
+	coll.invalidateDiagnostics(docURI)
 
+	return nil
+}
+
+// Save sends a textDocument/didSave notification for the document at uri.
+func (coll *LSPDocumentCollection) Save(ctx context.Context, docURI uri.URI) error {
+	coll.mu.Lock()
+	doc, ok := coll.documents[docURI]
+	coll.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("document not open: %s", docURI)
+	}
+
+	if err := doc.Save(ctx); err != nil {
+		return fmt.Errorf("failed to save document: %s", err)
+	}
+
+	return nil
+}
+
+// invalidateDiagnostics cancels any in flight re-diagnose request and schedules a new one after debounceDelay, so
+// a burst of edits to changed only triggers a single round trip to clangd once typing settles. Reverse
+// dependencies are approximated as every other currently open document, since clangd doesn't expose an explicit
+// include graph over JSON RPC.
+func (coll *LSPDocumentCollection) invalidateDiagnostics(changed uri.URI) {
+	coll.mu.Lock()
+	if coll.debounceCancel != nil {
+		coll.debounceCancel()
+	}
+	debounceCtx, cancel := context.WithCancel(context.Background())
+	coll.debounceCancel = cancel
+
+	reverseDeps := make([]*LSPDocument, 0, len(coll.documents))
+	for u, doc := range coll.documents {
+		if u == changed {
+			continue
+		}
+		reverseDeps = append(reverseDeps, doc)
+	}
+	coll.mu.Unlock()
+
+	if coll.notif != nil {
+		coll.notif.invalidateDiagnostics(changed)
+	}
+
+	go func() {
+		select {
+		case <-time.After(debounceDelay):
+		case <-debounceCtx.Done():
+			return
+		}
+
+		// clangd republishes diagnostics on its own once it re-parses a translation unit; requesting document
+		// symbols is enough to make it touch (and thus re-diagnose) a reverse dependency we didn't edit directly.
+		for _, doc := range reverseDeps {
+			if _, err := doc.server.DocumentSymbol(debounceCtx, &protocol.DocumentSymbolParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: doc.uri},
+			}); err != nil && coll.logger != nil {
+				coll.logger.Debug("failed to re-diagnose reverse dependency", zap.String("uri", string(doc.uri)), zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (coll *LSPDocumentCollection) CloseAll(ctx context.Context) error {
+	coll.mu.Lock()
+	docs := make([]*LSPDocument, 0, len(coll.documents))
 	for _, doc := range coll.documents {
+		docs = append(docs, doc)
+	}
+	coll.mu.Unlock()
+
+	errs := []string{}
+
+	for _, doc := range docs {
 		if err := doc.Close(ctx); err != nil {
 			errs = append(errs, fmt.Sprintf("failed to close %s: %s", doc.uri, err))
 		}
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestApplyContentChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		change  protocol.TextDocumentContentChangeEvent
+		want    string
+	}{
+		{
+			name:    "replace within a single line",
+			content: "const int a = 1;\nconst int b = 2;\n",
+			change: protocol.TextDocumentContentChangeEvent{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: 0, Character: 10},
+					End:   protocol.Position{Line: 0, Character: 11},
+				},
+				Text: "x",
+			},
+			want: "const int x = 1;\nconst int b = 2;\n",
+		},
+		{
+			name:    "insert at start of line",
+			content: "KC_A, KC_B\n",
+			change: protocol.TextDocumentContentChangeEvent{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: 0, Character: 0},
+					End:   protocol.Position{Line: 0, Character: 0},
+				},
+				Text: "// ",
+			},
+			want: "// KC_A, KC_B\n",
+		},
+		{
+			name:    "replace span across multiple lines",
+			content: "one\ntwo\nthree\n",
+			change: protocol.TextDocumentContentChangeEvent{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: 0, Character: 1},
+					End:   protocol.Position{Line: 2, Character: 2},
+				},
+				Text: "X",
+			},
+			want: "oXree\n",
+		},
+		{
+			name:    "out of range line falls back to a full replace",
+			content: "only line\n",
+			change: protocol.TextDocumentContentChangeEvent{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: 5, Character: 0},
+					End:   protocol.Position{Line: 5, Character: 0},
+				},
+				Text: "whole new document",
+			},
+			want: "whole new document",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := applyContentChange(test.content, test.change)
+			if got != test.want {
+				t.Errorf("applyContentChange() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -20,6 +20,9 @@ type LSPManager struct {
 	// logger outputs debug and error information
 	logger *zap.Logger
 
+	// pid is the OS process ID of the LSP server, or 0 if it isn't a local child process.
+	pid int
+
 	// stream used to communicate with the LSP server.
 	stream jsonrpc2.Stream
 
@@ -30,35 +33,68 @@ type LSPManager struct {
 	server clangdlsp.ClangdServer
 
 	// lspNotifications is used to communicate when different notifications are received from the LSP server
-	lspNotifications LSPNotifications
+	lspNotifications *LSPNotifications
 
 	// docColl is used to manage the lifecycle of files opened by the LSP server
 	docColl LSPDocumentCollection
+
+	// runErr receives the connection's terminal error once Run's read loop stops.
+	runErr <-chan error
 }
 
 // HandleLSPMsg runs when the JSON RPC connection communicating with the LSP server receives a message.
 func (manager LSPManager) HandleLSPMsg(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
 	// Handle known notifications
-	if req.Method() == protocol.MethodProgress {
-		// Progress notification
+	switch req.Method() {
+	case protocol.MethodProgress:
+		// Generic work done progress notification, covers backgroundIndexProgress as well as any other token a
+		// server decides to report against.
 		params := protocol.ProgressParams{}
 		if err := json.Unmarshal(req.Params(), &params); err != nil {
 			return fmt.Errorf("failed to unmarshall progress notification params: %s", err)
 		}
 
-		// Handle known progress tokens
-		if params.Token.String() == clangdlsp.ProgressTokenBackgroundIndexProgress {
-			// Clangd background index progress
-			bgIdxParams := clangdlsp.BackgroundIndexProgressParams{}
-			if err := json.Unmarshal(req.Params(), &bgIdxParams); err != nil {
-				return fmt.Errorf("failed to unmarshall background index progress params: %s", err)
-			}
-
-			// Send message on channel if the background indexing is complete
-			if bgIdxParams.Value.Kind == clangdlsp.BackgroundIndexProgressEnd {
-				manager.lspNotifications.backgroundIndexDone <- struct{}{}
-			}
+		manager.handleProgress(params)
+
+	case protocol.MethodTextDocumentPublishDiagnostics:
+		// Diagnostics notification, route to the per-document and workspace-wide diagnostics channels.
+		params := protocol.PublishDiagnosticsParams{}
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return fmt.Errorf("failed to unmarshall publish diagnostics params: %s", err)
+		}
+
+		manager.lspNotifications.publishDiagnostics(params)
+
+	case protocol.MethodWindowLogMessage:
+		// Log messages are re-emitted at the matching Zap level rather than surfaced to the frontend, matching
+		// how gopls logs server-side messages back through its own client logger.
+		params := protocol.LogMessageParams{}
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return fmt.Errorf("failed to unmarshall log message params: %s", err)
+		}
+
+		logLSPMessage(manager.logger, params.Type, params.Message)
+
+	case protocol.MethodWindowShowMessage:
+		params := protocol.ShowMessageParams{}
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return fmt.Errorf("failed to unmarshall show message params: %s", err)
+		}
+
+		manager.lspNotifications.showMessage(params)
+
+	case protocol.MethodWindowShowMessageRequest:
+		// qmk-gui doesn't yet offer the user a choice among MessageActionItems, so the request is surfaced as a
+		// show message and acknowledged with a null action, same as a showMessage notification.
+		params := protocol.ShowMessageRequestParams{}
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return fmt.Errorf("failed to unmarshall show message request params: %s", err)
 		}
+
+		manager.lspNotifications.showMessage(protocol.ShowMessageParams{
+			Type:    params.Type,
+			Message: params.Message,
+		})
 	}
 
 	// Reply with null to meat JSON spec
@@ -66,48 +102,178 @@ func (manager LSPManager) HandleLSPMsg(ctx context.Context, reply jsonrpc2.Repli
 	return reply(ctx, nil, nil)
 }
 
-// NewLSPManager creates a new LSPManager.
-// This method spawns a LSP server child process for the LSPManager to use.
-func NewLSPManager(ctx context.Context, logger *zap.Logger) (*LSPManager, error) {
-	// Start LSP server
-	proc, err := cmd.NewCmdCloser(ctx, logger, "clangd", []string{
-		//"--log=verbose",
-		"--limit-results=0",
-	})
+// handleProgress decodes a $/progress notification's WorkDoneProgress payload (Begin, Report, or End) into a
+// ProgressEvent and publishes it, keyed by token, so any progress the server reports - not just clangd's
+// background index - reaches subscribers.
+func (manager LSPManager) handleProgress(params protocol.ProgressParams) {
+	raw, err := json.Marshal(params.Value)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run C LSP: %s", err)
+		manager.logger.Error("failed to marshal progress value", zap.Error(err))
+		return
+	}
+
+	kind := struct {
+		Kind string `json:"kind"`
+	}{}
+	if err := json.Unmarshal(raw, &kind); err != nil {
+		manager.logger.Error("failed to unmarshal progress kind", zap.Error(err))
+		return
+	}
+
+	event := ProgressEvent{
+		Token: params.Token,
+		Kind:  kind.Kind,
+	}
+
+	switch kind.Kind {
+	case "begin":
+		begin := protocol.WorkDoneProgressBegin{}
+		if err := json.Unmarshal(raw, &begin); err != nil {
+			manager.logger.Error("failed to unmarshal progress begin", zap.Error(err))
+			return
+		}
+		event.Title = begin.Title
+		event.Message = begin.Message
+		event.Percentage = begin.Percentage
+
+	case "report":
+		report := protocol.WorkDoneProgressReport{}
+		if err := json.Unmarshal(raw, &report); err != nil {
+			manager.logger.Error("failed to unmarshal progress report", zap.Error(err))
+			return
+		}
+		event.Message = report.Message
+		event.Percentage = report.Percentage
+
+	case "end":
+		end := protocol.WorkDoneProgressEnd{}
+		if err := json.Unmarshal(raw, &end); err != nil {
+			manager.logger.Error("failed to unmarshal progress end", zap.Error(err))
+			return
+		}
+		event.Message = end.Message
+	}
+
+	manager.lspNotifications.progress(event)
+
+	// Preserved for callers that only care about clangd's background index finishing, rather than every token.
+	if params.Token.String() == clangdlsp.ProgressTokenBackgroundIndexProgress && kind.Kind == "end" {
+		manager.lspNotifications.notifyBackgroundIndexDone()
+	}
+}
+
+// logLSPMessage re-emits a window/logMessage notification at the Zap level matching its LSP MessageType.
+func logLSPMessage(logger *zap.Logger, msgType protocol.MessageType, message string) {
+	logger = logger.With(zap.String("source", "lsp"))
+
+	switch msgType {
+	case protocol.MessageTypeError:
+		logger.Error(message)
+	case protocol.MessageTypeWarning:
+		logger.Warn(message)
+	case protocol.MessageTypeInfo:
+		logger.Info(message)
+	default:
+		logger.Debug(message)
 	}
+}
 
-	logger.Info("running lsp")
+// NewLSPManager creates a new LSPManager communicating with the LSP server over transport, wires up all of its
+// collaborators, and only then starts reading from the connection via Run, so there is no window where the server
+// can emit a notification before HandleLSPMsg is installed to receive it. transport can be a spawned clangd child
+// process (see NewSpawnedLSPManager) or a connection to an already-running remote server (cmd.NetTransport).
+// notifications is the hub HandleLSPMsg publishes into; pass nil to have one created for this manager alone, or an
+// existing hub (as Supervisor does across restarts) so subscribers don't have to resubscribe to a new manager.
+func NewLSPManager(ctx context.Context, logger *zap.Logger, transport LSPTransport, notifications *LSPNotifications) (*LSPManager, error) {
+	logger.Info("running lsp", zap.Int("pid", transport.Pid()))
 
-	stream := jsonrpc2.NewStream(proc)
+	stream := jsonrpc2.NewStream(transport)
 	conn := jsonrpc2.NewConn(stream)
 
 	server := clangdlsp.NewClangdServer(conn, logger)
 
-	// Create document collection
-	docColl := LSPDocumentCollection{
-		server:    server.Server,
-		documents: []LSPDocument{},
+	if notifications == nil {
+		notifications = NewLSPNotifications()
 	}
+	lspNotifications := notifications
+
+	// Create document collection. The sync kind defaults to full and is narrowed to whatever the server actually
+	// declares once its Initialize response is known, via SetSyncKind.
+	docColl := NewLSPDocumentCollection(server.Server, protocol.TextDocumentSyncKindFull, lspNotifications, logger)
 
 	// Create LSPManager
-	manager := LSPManager{
+	manager := &LSPManager{
 		ctx:              ctx,
 		logger:           logger,
+		pid:              transport.Pid(),
 		stream:           stream,
 		conn:             conn,
 		server:           server,
-		lspNotifications: NewLSPNotifications(),
+		lspNotifications: lspNotifications,
 		docColl:          docColl,
 	}
 
-	// Start goroutine to handle JSON RPC messages
+	// All wiring above must complete before the read loop starts, so HandleLSPMsg is never called against a
+	// half-built manager.
+	manager.runErr = manager.Run(ctx)
+
+	//client := protocol.ClientDispatcher(conn, logger)
+
+	return manager, nil
+}
+
+// NewSpawnedLSPManager is a convenience wrapper around NewLSPManager which spawns clangd as a child process and
+// uses its stdio as the transport. This is the default way of running qmk-gui, as opposed to pointing it at an
+// already-running LSP server with NewLSPManager and a cmd.NetTransport.
+func NewSpawnedLSPManager(ctx context.Context, logger *zap.Logger, clangdArgs []string) (*LSPManager, error) {
+	proc, err := cmd.NewCmdCloser(ctx, logger, "clangd", clangdArgs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run C LSP: %s", err)
+	}
+
+	return NewLSPManager(ctx, logger, proc, nil)
+}
+
+// Run installs HandleLSPMsg on the JSON RPC connection and starts reading from the LSP server. Callers must not
+// issue requests against manager.server until Run has been called, since clangd may otherwise reply before
+// anything is listening. The returned channel receives the connection's terminal error (nil on a clean shutdown)
+// exactly once, when the connection to the LSP server closes.
+func (manager *LSPManager) Run(ctx context.Context) <-chan error {
+	manager.conn.Go(ctx, manager.HandleLSPMsg)
+
+	errCh := make(chan error, 1)
 	go func() {
-		conn.Go(ctx, manager.HandleLSPMsg)
+		<-manager.conn.Done()
+		errCh <- manager.conn.Err()
 	}()
 
-	//client := protocol.ClientDispatcher(conn, logger)
+	return errCh
+}
+
+// Err returns the channel which receives the connection's terminal error when the transport to the LSP server
+// fails or is closed, so callers can trigger a shutdown or a restart.
+func (manager *LSPManager) Err() <-chan error {
+	return manager.runErr
+}
+
+// Server returns the LSP server client wired to this manager's connection, for issuing requests like Hover or
+// Definition directly against clangd.
+func (manager *LSPManager) Server() clangdlsp.ClangdServer {
+	return manager.server
+}
+
+// Pid returns the OS process ID of the LSP server, or 0 if it isn't a local child process.
+func (manager *LSPManager) Pid() int {
+	return manager.pid
+}
+
+// Documents returns the document collection that this manager's Open/Change/Save calls route through.
+func (manager *LSPManager) Documents() *LSPDocumentCollection {
+	return &manager.docColl
+}
 
-	return &manager, nil
+// Notifications returns the notifications hub fed by this manager's HandleLSPMsg, for subscribing to diagnostics,
+// progress, and show message events.
+func (manager *LSPManager) Notifications() *LSPNotifications {
+	return manager.lspNotifications
 }
@@ -1,19 +1,178 @@
 package lsp
 
-// LSPNotifications is responsible for communicating to the rest of the system that certain LSP notifications have been received.
+import (
+	"sync"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// ProgressEvent is a single WorkDoneProgress notification (begin, report, or end) for some token, generalized
+// beyond clangd's backgroundIndexProgress to cover any $/progress a server reports.
+type ProgressEvent struct {
+	// Token identifies which piece of work this event belongs to, e.g. "backgroundIndexProgress".
+	Token protocol.ProgressToken
+
+	// Kind is one of "begin", "report", or "end".
+	Kind string
+
+	// Title is only set on a "begin" event.
+	Title string
+
+	// Message is a human readable status, set on any kind of event.
+	Message string
+
+	// Percentage is the reported completion percentage, 0-100. Only meaningful on "begin" and "report" events.
+	Percentage uint32
+}
+
+// LSPNotifications is responsible for communicating to the rest of the system that certain LSP notifications have
+// been received.
 type LSPNotifications struct {
-	// backgroundIndexDone is a channel which has a message sent on it when the LSP server indicates its background index process is complete.
+	// backgroundIndexDone is a channel which has a message sent on it when the LSP server indicates its background
+	// index process is complete.
 	backgroundIndexDone chan struct{}
+
+	mu sync.Mutex
+
+	// diagnostics holds one channel per document URI, fed by publishDiagnostics whenever the LSP server sends
+	// new diagnostics for that document.
+	diagnostics map[uri.URI]chan []protocol.Diagnostic
+
+	// diagnosticsFanout receives every textDocument/publishDiagnostics notification across the whole workspace,
+	// for consumers (like the Wails frontend) that want to watch all open documents at once.
+	diagnosticsFanout chan protocol.PublishDiagnosticsParams
+
+	// progressFanout receives every $/progress notification, keyed by token via ProgressEvent.Token.
+	progressFanout chan ProgressEvent
+
+	// showMessages receives window/showMessage and window/showMessageRequest notifications, for the frontend to
+	// render as toasts.
+	showMessages chan protocol.ShowMessageParams
 }
 
 // NewLSPNotifications creates a new LSPNotifications structure.
-func NewLSPNotifications() LSPNotifications {
-	return LSPNotifications{
-		backgroundIndexDone: make(chan struct{}),
+func NewLSPNotifications() *LSPNotifications {
+	return &LSPNotifications{
+		backgroundIndexDone: make(chan struct{}, 1),
+		diagnostics:         map[uri.URI]chan []protocol.Diagnostic{},
+		diagnosticsFanout:   make(chan protocol.PublishDiagnosticsParams, 16),
+		progressFanout:      make(chan ProgressEvent, 16),
+		showMessages:        make(chan protocol.ShowMessageParams, 16),
 	}
 }
 
-// BackgroundIndexDone returns a channel which receives a notification when the LSP server indicates its background index process is complete.
-func (notif LSPNotifications) BackgroundIndexDone() <-chan struct{} {
+// BackgroundIndexDone returns a channel which receives a notification when the LSP server indicates its background
+// index process is complete.
+func (notif *LSPNotifications) BackgroundIndexDone() <-chan struct{} {
 	return notif.backgroundIndexDone
 }
+
+// notifyBackgroundIndexDone publishes to backgroundIndexDone without blocking. clangd re-runs its background index
+// more than once per session (e.g. after a restart or a config.h edit), but backgroundIndexDone is only ever read
+// once by startup code waiting for the first index to finish, so a later "end" event must not pile up behind a
+// blocking send on the JSON-RPC read loop.
+func (notif *LSPNotifications) notifyBackgroundIndexDone() {
+	select {
+	case notif.backgroundIndexDone <- struct{}{}:
+	default:
+	}
+}
+
+// Diagnostics returns a channel which receives the latest diagnostics clangd has published for docURI. The
+// channel is created on first access and reused afterwards, so callers can subscribe before the document is even
+// opened.
+func (notif *LSPNotifications) Diagnostics(docURI uri.URI) <-chan []protocol.Diagnostic {
+	return notif.diagnosticsChan(docURI)
+}
+
+// DiagnosticsFanout returns a channel which receives every publishDiagnostics notification across the whole
+// workspace, so the frontend can subscribe once instead of per-document.
+func (notif *LSPNotifications) DiagnosticsFanout() <-chan protocol.PublishDiagnosticsParams {
+	return notif.diagnosticsFanout
+}
+
+// diagnosticsChan returns (creating if necessary) the per-document diagnostics channel for docURI.
+func (notif *LSPNotifications) diagnosticsChan(docURI uri.URI) chan []protocol.Diagnostic {
+	notif.mu.Lock()
+	defer notif.mu.Unlock()
+
+	ch, ok := notif.diagnostics[docURI]
+	if !ok {
+		ch = make(chan []protocol.Diagnostic, 1)
+		notif.diagnostics[docURI] = ch
+	}
+
+	return ch
+}
+
+// publishDiagnostics routes an incoming textDocument/publishDiagnostics notification to the per-document channel
+// and the workspace-wide fan out. Both channels are buffered and drop the oldest pending value rather than block,
+// since only the latest diagnostics for a document matter.
+func (notif *LSPNotifications) publishDiagnostics(params protocol.PublishDiagnosticsParams) {
+	ch := notif.diagnosticsChan(params.URI)
+	sendLatest(ch, params.Diagnostics)
+
+	select {
+	case notif.diagnosticsFanout <- params:
+	default:
+		<-notif.diagnosticsFanout
+		notif.diagnosticsFanout <- params
+	}
+}
+
+// Progress returns a channel which receives every WorkDoneProgress event (begin, report, end) any LSP request or
+// background task reports, across all tokens. Filter on ProgressEvent.Token for a specific piece of work.
+func (notif *LSPNotifications) Progress() <-chan ProgressEvent {
+	return notif.progressFanout
+}
+
+// progress publishes a ProgressEvent, dropping the oldest pending event first if the buffered channel is full.
+func (notif *LSPNotifications) progress(event ProgressEvent) {
+	select {
+	case notif.progressFanout <- event:
+	default:
+		<-notif.progressFanout
+		notif.progressFanout <- event
+	}
+}
+
+// ShowMessages returns a channel which receives every window/showMessage and window/showMessageRequest
+// notification from the LSP server, for the frontend to render as toasts.
+func (notif *LSPNotifications) ShowMessages() <-chan protocol.ShowMessageParams {
+	return notif.showMessages
+}
+
+// showMessage publishes a ShowMessageParams, dropping the oldest pending message first if the buffered channel is
+// full.
+func (notif *LSPNotifications) showMessage(params protocol.ShowMessageParams) {
+	select {
+	case notif.showMessages <- params:
+	default:
+		<-notif.showMessages
+		notif.showMessages <- params
+	}
+}
+
+// invalidateDiagnostics drops any stale diagnostics cached for docURI so a consumer reading from Diagnostics
+// doesn't see a result from before the most recent edit while clangd re-parses.
+func (notif *LSPNotifications) invalidateDiagnostics(docURI uri.URI) {
+	ch := notif.diagnosticsChan(docURI)
+	select {
+	case <-ch:
+	default:
+	}
+}
+
+// sendLatest sends value on ch, dropping a pending value first if the buffered channel is full.
+func sendLatest(ch chan []protocol.Diagnostic, value []protocol.Diagnostic) {
+	select {
+	case ch <- value:
+	default:
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- value
+	}
+}
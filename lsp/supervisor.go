@@ -0,0 +1,219 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SupervisorState describes the current health of the LSP connection a Supervisor manages.
+type SupervisorState int
+
+const (
+	SupervisorStarting SupervisorState = iota
+	SupervisorReady
+	SupervisorRestarting
+	SupervisorFailed
+)
+
+func (s SupervisorState) String() string {
+	switch s {
+	case SupervisorStarting:
+		return "Starting"
+	case SupervisorReady:
+		return "Ready"
+	case SupervisorRestarting:
+		return "Restarting"
+	case SupervisorFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
+
+// defaultMaxRestarts is how many consecutive times Supervisor will respawn the LSP server after an unexpected
+// exit before giving up and reporting SupervisorFailed.
+const defaultMaxRestarts = 10
+
+// SpawnFunc creates a new LSPTransport to talk to a fresh LSP server instance, e.g. by spawning a new clangd
+// child process. Supervisor calls it once up front and again after every unexpected exit.
+type SpawnFunc func(ctx context.Context) (LSPTransport, error)
+
+// InitFunc runs the Initialize/Initialized handshake against a freshly (re)started LSPManager. Supervisor calls
+// it once up front and again after every restart, since clangd requires the handshake before it will accept any
+// other requests.
+type InitFunc func(ctx context.Context, manager *LSPManager) error
+
+// Supervisor wraps an LSPManager and keeps it alive. If the LSP server exits unexpectedly, Supervisor tears down
+// the JSON-RPC connection, respawns it with exponential backoff (up to maxRestarts consecutive attempts),
+// re-runs the Initialize/Initialized handshake, and reopens every document that was tracked by the previous
+// LSPManager's LSPDocumentCollection at its last known version. This matters because clangd is known to crash on
+// malformed QMK translation units, and losing all open document state on every crash is a poor user experience.
+type Supervisor struct {
+	ctx    context.Context
+	logger *zap.Logger
+
+	spawn       SpawnFunc
+	init        InitFunc
+	maxRestarts int
+
+	mu      sync.Mutex
+	manager *LSPManager
+
+	// notifications is the single notifications hub shared by every LSPManager this Supervisor spawns, so
+	// subscribers can subscribe once and keep receiving events across a crash-restart instead of reading from a
+	// manager that's been replaced out from under them.
+	notifications *LSPNotifications
+
+	state chan SupervisorState
+}
+
+// NewSupervisor creates a Supervisor, spawns the first LSP server via spawn, and runs the initial handshake via
+// init. maxRestarts bounds how many consecutive unexpected exits Supervisor will attempt to recover from before
+// reporting SupervisorFailed; 0 or negative selects defaultMaxRestarts.
+func NewSupervisor(ctx context.Context, logger *zap.Logger, spawn SpawnFunc, init InitFunc, maxRestarts int) (*Supervisor, error) {
+	if maxRestarts <= 0 {
+		maxRestarts = defaultMaxRestarts
+	}
+
+	sup := &Supervisor{
+		ctx:           ctx,
+		logger:        logger,
+		spawn:         spawn,
+		init:          init,
+		maxRestarts:   maxRestarts,
+		notifications: NewLSPNotifications(),
+		state:         make(chan SupervisorState, 1),
+	}
+	sup.setState(SupervisorStarting)
+
+	if err := sup.start(ctx); err != nil {
+		sup.setState(SupervisorFailed)
+		return nil, fmt.Errorf("failed to start supervised LSP server: %s", err)
+	}
+
+	go sup.watch()
+
+	return sup, nil
+}
+
+// State returns a channel which receives the Supervisor's state every time it changes, so the frontend can show a
+// status indicator and disable LSP-dependent UI while the server is down.
+func (sup *Supervisor) State() <-chan SupervisorState {
+	return sup.state
+}
+
+// Manager returns the currently active LSPManager. It is replaced out from under the caller on a restart, so
+// callers that might hold onto it across a restart should re-fetch it via Manager rather than caching the pointer.
+func (sup *Supervisor) Manager() *LSPManager {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	return sup.manager
+}
+
+// Notifications returns the notifications hub shared by every LSPManager this Supervisor spawns. Unlike
+// Manager().Notifications(), the returned hub stays valid across restarts, so callers should subscribe to it once
+// rather than re-fetching it from the current manager.
+func (sup *Supervisor) Notifications() *LSPNotifications {
+	return sup.notifications
+}
+
+// setState publishes state, dropping a pending unread state first if the buffered channel is full, so State()
+// always reflects the most recent transition.
+func (sup *Supervisor) setState(state SupervisorState) {
+	select {
+	case sup.state <- state:
+	default:
+		<-sup.state
+		sup.state <- state
+	}
+}
+
+// start spawns a transport, builds a new LSPManager, and runs the handshake, installing the result as the active
+// manager on success.
+func (sup *Supervisor) start(ctx context.Context) error {
+	transport, err := sup.spawn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to spawn LSP server: %s", err)
+	}
+
+	manager, err := NewLSPManager(ctx, sup.logger, transport, sup.notifications)
+	if err != nil {
+		return fmt.Errorf("failed to create LSP manager: %s", err)
+	}
+
+	if err := sup.init(ctx, manager); err != nil {
+		return fmt.Errorf("failed to initialize LSP server: %s", err)
+	}
+
+	sup.mu.Lock()
+	sup.manager = manager
+	sup.mu.Unlock()
+
+	sup.setState(SupervisorReady)
+
+	return nil
+}
+
+// watch waits for the active manager's connection to fail, then restarts it with exponential backoff. It returns
+// once the supervised context is done or restarts have been exhausted.
+func (sup *Supervisor) watch() {
+	for {
+		sup.mu.Lock()
+		manager := sup.manager
+		sup.mu.Unlock()
+
+		select {
+		case <-sup.ctx.Done():
+			return
+		case err := <-manager.Err():
+			sup.logger.Warn("LSP server connection closed, restarting", zap.Error(err))
+		}
+
+		sup.setState(SupervisorRestarting)
+
+		previousDocs := manager.docColl.Snapshots()
+
+		if !sup.restart(previousDocs) {
+			sup.setState(SupervisorFailed)
+			return
+		}
+	}
+}
+
+// restart retries start with exponential backoff up to maxRestarts times, reopening previousDocs against the new
+// manager once a restart succeeds. Returns false once every attempt has been exhausted.
+func (sup *Supervisor) restart(previousDocs []DocumentSnapshot) bool {
+	backoff := time.Second
+
+	for attempt := 1; attempt <= sup.maxRestarts; attempt++ {
+		sup.logger.Info("restarting LSP server", zap.Int("attempt", attempt), zap.Int("maxRestarts", sup.maxRestarts))
+
+		if err := sup.start(sup.ctx); err != nil {
+			sup.logger.Error("failed to restart LSP server", zap.Int("attempt", attempt), zap.Error(err))
+
+			select {
+			case <-sup.ctx.Done():
+				return false
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			continue
+		}
+
+		manager := sup.Manager()
+		for _, doc := range previousDocs {
+			if err := manager.docColl.Reopen(sup.ctx, doc); err != nil {
+				sup.logger.Error("failed to reopen document after LSP restart", zap.String("uri", string(doc.URI)), zap.Error(err))
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
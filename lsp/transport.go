@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// LSPTransport is the duplex byte stream NewLSPManager communicates with an LSP server over.
+// cmd.CmdCloser (spawned clangd over stdio) and cmd.NetTransport (an already-running server over TCP or a unix
+// socket) both satisfy this interface.
+type LSPTransport interface {
+	io.ReadWriteCloser
+
+	// Pid returns the OS process ID of the LSP server, or 0 if it isn't a local child process.
+	Pid() int
+}
+
+// traceTransport wraps an LSPTransport and appends every raw JSON-RPC frame read from or written to it to a trace
+// file, for debugging the wire protocol between qmk-gui and the LSP server (see the --lsp-trace flag).
+type traceTransport struct {
+	LSPTransport
+	trace *os.File
+}
+
+// NewTraceTransport wraps transport so every byte read from or written to it is also appended to trace, prefixed
+// with a direction marker and timestamp.
+func NewTraceTransport(transport LSPTransport, trace *os.File) LSPTransport {
+	return &traceTransport{
+		LSPTransport: transport,
+		trace:        trace,
+	}
+}
+
+func (t *traceTransport) Read(p []byte) (int, error) {
+	n, err := t.LSPTransport.Read(p)
+	if n > 0 {
+		t.writeFrame("<-", p[:n])
+	}
+	return n, err
+}
+
+func (t *traceTransport) Write(p []byte) (int, error) {
+	n, err := t.LSPTransport.Write(p)
+	if n > 0 {
+		t.writeFrame("->", p[:n])
+	}
+	return n, err
+}
+
+func (t *traceTransport) Close() error {
+	closeErr := t.LSPTransport.Close()
+	if err := t.trace.Close(); err != nil && closeErr == nil {
+		closeErr = err
+	}
+	return closeErr
+}
+
+func (t *traceTransport) writeFrame(direction string, frame []byte) {
+	t.trace.WriteString(time.Now().Format(time.RFC3339Nano))
+	t.trace.WriteString(" ")
+	t.trace.WriteString(direction)
+	t.trace.WriteString(" ")
+	t.trace.Write(frame)
+	t.trace.WriteString("\n")
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"go.lsp.dev/protocol"
-	"go.lsp.dev/uri"
 	"go.uber.org/zap"
 
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,39 +13,71 @@ import (
 	"embed"
 
 	"github.com/Noah-Huppert/qmk-gui/clangdlsp"
+	"github.com/Noah-Huppert/qmk-gui/cmd"
+	"github.com/Noah-Huppert/qmk-gui/lsp"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 )
 
-//go:embed all:frontend/dist
-var assets embed.FS
+// remoteLSPAddr points qmk-gui at an already-running LSP server instead of spawning clangd, e.g.
+// "tcp://localhost:9999" or "unix:///tmp/clangd.sock". Useful for running clangd inside a container or VM near
+// the qmk_firmware checkout, and for integration tests against a mock LSP server.
+var remoteLSPAddr = flag.String("remote", "", "connect to an already-running LSP server instead of spawning clangd (tcp://host:port or unix:///path)")
+
+// lspTracePath dumps every raw JSON-RPC frame exchanged with the LSP server to the given file, mirroring gopls'
+// rpc-trace command-line control.
+var lspTracePath = flag.String("lsp-trace", "", "dump raw JSON-RPC frames exchanged with the LSP server to this file")
+
+// newLSPTransport picks the LSP transport to use based on remoteLSPAddr: a remote connection if set, otherwise a
+// spawned clangd child process communicating over stdio. If lspTracePath is set, the chosen transport is wrapped
+// so every frame is also appended to that file.
+func newLSPTransport(ctx context.Context, logger *zap.Logger) (lsp.LSPTransport, error) {
+	var transport lsp.LSPTransport
+	var err error
+
+	if *remoteLSPAddr != "" {
+		logger.Info("connecting to remote LSP server", zap.String("addr", *remoteLSPAddr))
+		transport, err = cmd.NewNetTransport(*remoteLSPAddr)
+	} else {
+		transport, err = cmd.NewCmdCloser(ctx, logger, "clangd", []string{
+			//"--log=verbose",
+			"--limit-results=0",
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
 
-// App struct
-type App struct {
-	ctx context.Context
-}
+	if *lspTracePath != "" {
+		trace, err := os.Create(*lspTracePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LSP trace file: %s", err)
+		}
 
-// NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
-}
+		logger.Info("tracing LSP JSON-RPC frames", zap.String("path", *lspTracePath))
+		transport = lsp.NewTraceTransport(transport, trace)
+	}
 
-// startup is called when the app starts. The context is saved
-// so we can call the runtime methods
-func (a *App) startup(ctx context.Context) {
-	a.ctx = ctx
+	return transport, nil
 }
 
-// Greet returns a greeting for the given name
-func (a *App) Greet(name string) string {
-	return fmt.Sprintf("Hello %s, It's show time!", name)
+//go:embed all:frontend/dist
+var assets embed.FS
+
+// requestedSemanticTokenTypes is the full standard LSP token type set, advertised in the Initialize request's
+// TextDocument.SemanticTokens capability so clangd knows qmk-gui understands all of them. The legend clangd actually
+// responds with (see extractSemanticTokensLegend) is what's used to decode token type indices, since a server is
+// free to return a narrower or differently ordered legend than what the client requested.
+var requestedSemanticTokenTypes = []string{
+	"namespace", "type", "class", "enum", "interface", "struct", "typeParameter", "parameter", "variable",
+	"property", "enumMember", "event", "function", "method", "macro", "keyword", "modifier", "comment", "string",
+	"number", "regexp", "operator",
 }
 
 func main() {
-	// Setup context and logger
-	ctx := context.Background()
+	flag.Parse()
 
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -66,7 +98,13 @@ func main() {
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
-		OnStartup:        app.startup,
+		OnStartup: func(ctx context.Context) {
+			app.startup(ctx)
+
+			// Runs in the background since bringing up the LSP server (spawning clangd, waiting for its
+			// background index, etc.) shouldn't block the window from appearing.
+			go startLSP(ctx, logger, app)
+		},
 		Bind: []interface{}{
 			app,
 		},
@@ -75,175 +113,154 @@ func main() {
 	if err != nil {
 		println("Error:", err.Error())
 	}
+}
 
-	// Initialize LSP
-	logger.Info("initializing C LSP")
+// startLSP brings up the supervised connection to the LSP server, runs the Initialize/Initialized handshake,
+// opens the keyboard's keymap.c, and wires the result into app so its bound methods can serve the frontend.
+func startLSP(ctx context.Context, logger *zap.Logger, app *App) {
+	logger.Info("starting C LSP")
 
 	cwd, err := os.Getwd()
 	if err != nil {
-		logger.Fatal("failed to get working directory", zap.Error(err))
+		logger.Error("failed to get working directory", zap.Error(err))
+		return
 	}
-
 	qmkFirmwareDir := filepath.Join(cwd, "../qmk_firmware")
 
-	initRes, err := server.Initialize(ctx, &clangdlsp.InitializeParams{
-		InitializeParams: protocol.InitializeParams{
-			ClientInfo: &protocol.ClientInfo{
-				Name:    "qmk-gui",
-				Version: "pre-alpha",
-			},
-			Locale: "en-us",
-			Capabilities: protocol.ClientCapabilities{
-				Workspace: &protocol.WorkspaceClientCapabilities{
-					WorkspaceFolders: true,
-					SemanticTokens: &protocol.SemanticTokensWorkspaceClientCapabilities{
-						RefreshSupport: true,
+	var caps CapabilitiesDTO
+	var semanticTokenLegend []string
+
+	init := func(ctx context.Context, manager *lsp.LSPManager) error {
+		server := manager.Server()
+
+		initRes, err := server.Initialize(ctx, &clangdlsp.InitializeParams{
+			InitializeParams: protocol.InitializeParams{
+				ClientInfo: &protocol.ClientInfo{
+					Name:    "qmk-gui",
+					Version: "pre-alpha",
+				},
+				Locale: "en-us",
+				Capabilities: protocol.ClientCapabilities{
+					Workspace: &protocol.WorkspaceClientCapabilities{
+						WorkspaceFolders: true,
+						SemanticTokens: &protocol.SemanticTokensWorkspaceClientCapabilities{
+							RefreshSupport: true,
+						},
+						Symbol: &protocol.WorkspaceSymbolClientCapabilities{
+							DynamicRegistration: true,
+							SymbolKind: &protocol.SymbolKindCapabilities{
+								ValueSet: []protocol.SymbolKind{
+									protocol.SymbolKindFile,
+									protocol.SymbolKindModule,
+									protocol.SymbolKindNamespace,
+									protocol.SymbolKindPackage,
+									protocol.SymbolKindClass,
+									protocol.SymbolKindMethod,
+									protocol.SymbolKindProperty,
+									protocol.SymbolKindField,
+									protocol.SymbolKindConstructor,
+									protocol.SymbolKindEnum,
+									protocol.SymbolKindInterface,
+									protocol.SymbolKindFunction,
+									protocol.SymbolKindVariable,
+									protocol.SymbolKindConstant,
+									protocol.SymbolKindString,
+									protocol.SymbolKindNumber,
+									protocol.SymbolKindBoolean,
+									protocol.SymbolKindArray,
+									protocol.SymbolKindObject,
+									protocol.SymbolKindKey,
+									protocol.SymbolKindNull,
+									protocol.SymbolKindEnumMember,
+									protocol.SymbolKindStruct,
+									protocol.SymbolKindEvent,
+									protocol.SymbolKindOperator,
+									protocol.SymbolKindTypeParameter,
+								},
+							},
+						},
+					},
+					Window: &protocol.WindowClientCapabilities{
+						WorkDoneProgress: true,
 					},
-					Symbol: &protocol.WorkspaceSymbolClientCapabilities{
-						DynamicRegistration: true,
-						SymbolKind: &protocol.SymbolKindCapabilities{
-							ValueSet: []protocol.SymbolKind{
-								protocol.SymbolKindFile,
-								protocol.SymbolKindModule,
-								protocol.SymbolKindNamespace,
-								protocol.SymbolKindPackage,
-								protocol.SymbolKindClass,
-								protocol.SymbolKindMethod,
-								protocol.SymbolKindProperty,
-								protocol.SymbolKindField,
-								protocol.SymbolKindConstructor,
-								protocol.SymbolKindEnum,
-								protocol.SymbolKindInterface,
-								protocol.SymbolKindFunction,
-								protocol.SymbolKindVariable,
-								protocol.SymbolKindConstant,
-								protocol.SymbolKindString,
-								protocol.SymbolKindNumber,
-								protocol.SymbolKindBoolean,
-								protocol.SymbolKindArray,
-								protocol.SymbolKindObject,
-								protocol.SymbolKindKey,
-								protocol.SymbolKindNull,
-								protocol.SymbolKindEnumMember,
-								protocol.SymbolKindStruct,
-								protocol.SymbolKindEvent,
-								protocol.SymbolKindOperator,
-								protocol.SymbolKindTypeParameter,
+					TextDocument: &protocol.TextDocumentClientCapabilities{
+						Synchronization: &protocol.TextDocumentSyncClientCapabilities{
+							DynamicRegistration: true,
+						},
+						PublishDiagnostics: &protocol.PublishDiagnosticsClientCapabilities{
+							RelatedInformation:     true,
+							VersionSupport:         true,
+							CodeDescriptionSupport: true,
+							DataSupport:            true,
+						},
+						SemanticTokens: &protocol.SemanticTokensClientCapabilities{
+							Requests: protocol.SemanticTokensClientCapabilitiesRequests{
+								Full: true,
 							},
+							TokenTypes: requestedSemanticTokenTypes,
+							Formats:    []protocol.TokenFormat{protocol.TokenFormatRelative},
 						},
 					},
 				},
-				Window: &protocol.WindowClientCapabilities{
-					WorkDoneProgress: true,
-				},
-				TextDocument: &protocol.TextDocumentClientCapabilities{
-					Synchronization: &protocol.TextDocumentSyncClientCapabilities{
-						DynamicRegistration: true,
-					},
-					PublishDiagnostics: &protocol.PublishDiagnosticsClientCapabilities{
-						RelatedInformation:     true,
-						VersionSupport:         true,
-						CodeDescriptionSupport: true,
-						DataSupport:            true,
+				WorkspaceFolders: []protocol.WorkspaceFolder{
+					{
+						Name: "qmk_firmware",
+						URI:  fmt.Sprintf("file://%s", qmkFirmwareDir),
 					},
 				},
+				ProcessID: int32(manager.Pid()),
 			},
-			WorkspaceFolders: []protocol.WorkspaceFolder{
-				{
-					Name: "qmk_firmware",
-					URI:  fmt.Sprintf("file://%s", qmkFirmwareDir),
-				},
+			InitializationOptions: clangdlsp.InitializationOptions{
+				ClangdFileStatus: true,
 			},
-			ProcessID: int32(proc.Pid()),
-		},
-		InitializationOptions: clangdlsp.InitializationOptions{
-			ClangdFileStatus: true,
-		},
-	})
-	if err != nil {
-		logger.Fatal("failed to initialize C LSP", zap.Error(err))
-	}
-
-	// Check for required LSP capabilities
-	if !initRes.ServerCapabilities.ASTProvider {
-		logger.Fatal("LSP server does not have AST capability", zap.Any("initRes", initRes))
-	} else {
-		logger.Debug("LSP server has AST capability")
-	}
-
-	if workspaceSymbolProvider, ok := initRes.InitializeResult.Capabilities.WorkspaceSymbolProvider.(bool); ok {
-		if !workspaceSymbolProvider {
-			logger.Fatal("LSP server does not have workspace symbols capability", zap.Any("initRes.InitializeResult", initRes.InitializeResult))
-		} else {
-			logger.Debug("LSP server has workspace symbols capability")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize C LSP: %s", err)
 		}
-	}
 
-	if err = server.Initialized(ctx, nil); err != nil {
-		logger.Fatal("failed to send initialized notification", zap.Error(err))
-	}
+		caps.ASTProvider = initRes.ServerCapabilities.ASTProvider
+		if workspaceSymbolProvider, ok := initRes.InitializeResult.Capabilities.WorkspaceSymbolProvider.(bool); ok {
+			caps.WorkspaceSymbolProvider = workspaceSymbolProvider
+		}
 
-	logger.Info("initialized C LSP")
+		semanticTokenLegend = extractSemanticTokensLegend(initRes.InitializeResult.Capabilities.SemanticTokensProvider)
+
+		// TextDocumentSync is either a bare TextDocumentSyncKind or a TextDocumentSyncOptions object with a "change"
+		// field; clangd sends the latter.
+		switch syncKind := initRes.InitializeResult.Capabilities.TextDocumentSync.(type) {
+		case float64:
+			manager.Documents().SetSyncKind(protocol.TextDocumentSyncKind(syncKind))
+		case map[string]interface{}:
+			if change, ok := syncKind["change"].(float64); ok {
+				manager.Documents().SetSyncKind(protocol.TextDocumentSyncKind(change))
+			}
+		}
 
-	// Open file
-	keymapCFilePath := filepath.Join(qmkFirmwareDir, "keyboards/moonlander/keymaps/default/keymap.c")
-	keymapCURI := uri.File(keymapCFilePath)
-	if err = docColl.Open(ctx, keymapCURI); err != nil {
-		logger.Fatal("failed to open keymap.c", zap.Error(err))
-	}
+		if err := server.Initialized(ctx, &protocol.InitializedParams{}); err != nil {
+			return fmt.Errorf("failed to send initialized notification: %s", err)
+		}
 
-	/* link, err := server.DocumentLink(ctx, &protocol.DocumentLinkParams{
-		TextDocument: protocol.TextDocumentIdentifier{
-			URI: keymapCURI,
-		},
-	})
-	if err != nil {
-		logger.Fatal("failed to get document links", zap.Error(err))
+		return nil
 	}
 
-	logger.Debug("document links", zap.Any("link", link)) */
-	/* bgIdxTok := protocol.NewProgressToken("backgroundIndexProgress")
-	err = client.WorkDoneProgressCreate(ctx, &protocol.WorkDoneProgressCreateParams{
-		Token: *bgIdxTok,
-	})
-	if err != nil {
-		logger.Fatal("failed to create background index progress token", zap.Error(err))
-	} */
-
-	/* client.Progress(ctx, &protocol.ProgressParams{
-		Token: *bgIdxTok,
-	}) */
-
-	/* symbols, err := client.DocumentSymbol(ctx, &protocol.DocumentSymbolParams{
-		TextDocument: protocol.TextDocumentIdentifier{
-			URI: keymapCURI,
-		},
-	}) */
-	// Search for symbols
-	// Doesn't seem like a blank search can be provided
-	<-backgroundIndexDone
-	symbols, err := server.Symbols(ctx, &protocol.WorkspaceSymbolParams{
-		Query: "",
-		WorkDoneProgressParams: protocol.WorkDoneProgressParams{
-			WorkDoneToken: protocol.NewProgressToken("symbols"),
-		},
-	})
+	supervisor, err := lsp.NewSupervisor(ctx, logger, func(ctx context.Context) (lsp.LSPTransport, error) {
+		return newLSPTransport(ctx, logger)
+	}, init, 0)
 	if err != nil {
-		logger.Fatal("failed to list symbols", zap.Error(err))
+		logger.Error("failed to start supervised LSP server", zap.Error(err))
+		return
 	}
 
-	logger.Info("symbols", zap.Any("symbols", symbols))
+	logger.Info("initialized C LSP", zap.Any("capabilities", caps))
 
-	// Cleanup server
-	if err := docColl.CloseAll(ctx); err != nil {
-		logger.Fatal("failed to send close events for documents: %s", zap.Error(err))
-	}
+	app.SetSupervisor(supervisor, caps, semanticTokenLegend)
 
-	if err = server.Shutdown(ctx); err != nil {
-		logger.Fatal("failed to shutdown C LSP", zap.Error(err))
-	}
+	// Wait for clangd's background index to finish before opening keymap.c. The App's bound methods, ListSymbols
+	// included, are already live from SetSupervisor above and do not wait for the index.
+	<-supervisor.Notifications().BackgroundIndexDone()
 
-	if err = server.Exit(ctx); err != nil {
-		logger.Fatal("failed to exit C LSP", zap.Error(err))
+	keymapCFilePath := filepath.Join(qmkFirmwareDir, "keyboards/moonlander/keymaps/default/keymap.c")
+	if _, err := app.OpenKeymap(keymapCFilePath); err != nil {
+		logger.Error("failed to open keymap.c", zap.Error(err))
 	}
 }